@@ -4,36 +4,122 @@ import (
 	"bytes"
 	"io"
 	"net/http"
+	"strings"
 )
 
+// bufferedResponseWriter buffers a plain HTML 200 response so withInjectReload
+// can rewrite it before it reaches the client, while everything else (non-HTML
+// content, redirects, 304s, Range/206 responses) is streamed straight through
+// without ever touching buf. The decision between the two modes is made as
+// soon as the status code and Content-Type are known, from either an explicit
+// WriteHeader call or the first Write.
 type bufferedResponseWriter struct {
 	http.ResponseWriter
-	buf    bytes.Buffer
-	status int
+	buf bytes.Buffer
+
+	status       int  // status passed to WriteHeader; 0 until set
+	headerCalled bool // the handler called WriteHeader explicitly
+	headerSent   bool // status and headers were forwarded to ResponseWriter
+	passthrough  bool // writes are forwarded directly instead of being buffered
+	writeErr     error
 }
 
 func newBufferedResponseWriter(w http.ResponseWriter) *bufferedResponseWriter {
-	return &bufferedResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	return &bufferedResponseWriter{ResponseWriter: w}
 }
 
-func (bw *bufferedResponseWriter) Write(b []byte) (int, error) {
-	if bw.status == 0 {
-		bw.status = http.StatusOK
+func (bw *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if bw.headerCalled {
+		return
+	}
+	bw.headerCalled = true
+	bw.status = statusCode
+	if !bw.bufferable() {
+		bw.passthrough = true
+		bw.sendHeader()
 	}
-	bw.buf.Reset()
-	return bw.buf.Write(b)
 }
 
-func (bw *bufferedResponseWriter) WriteHeader(statusCode int) {
-	bw.status = statusCode
+// bufferable reports whether the response seen so far is still a candidate
+// for buffering: an HTML response with no status code yet, or an explicit 200.
+func (bw *bufferedResponseWriter) bufferable() bool {
+	if bw.status != 0 && bw.status != http.StatusOK {
+		return false
+	}
+	if ct := bw.Header().Get("Content-Type"); ct != "" && !strings.Contains(ct, HTMLContentType) {
+		return false
+	}
+	return true
+}
+
+func (bw *bufferedResponseWriter) sendHeader() {
+	if bw.headerSent {
+		return
+	}
+	status := bw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	bw.ResponseWriter.WriteHeader(status)
+	bw.headerSent = true
+}
+
+func (bw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	// A previous write to the real ResponseWriter already failed (e.g. the
+	// client disconnected); don't keep buffering or try it again.
+	if bw.writeErr != nil {
+		return 0, bw.writeErr
+	}
+
+	if !bw.passthrough {
+		if !bw.headerCalled && bw.Header().Get("Content-Type") == "" {
+			bw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		if !bw.bufferable() {
+			bw.passthrough = true
+			bw.sendHeader()
+			if bw.buf.Len() > 0 {
+				if _, err := bw.ResponseWriter.Write(bw.buf.Bytes()); err != nil {
+					bw.writeErr = err
+					return 0, err
+				}
+				bw.buf.Reset()
+			}
+		}
+	}
+
+	if bw.passthrough {
+		n, err := bw.ResponseWriter.Write(b)
+		if err != nil {
+			bw.writeErr = err
+		}
+		return n, err
+	}
+
+	return bw.buf.Write(b)
 }
 
 func (bw *bufferedResponseWriter) Flush() {
-	flusher := bw.ResponseWriter.(http.Flusher)
-	flusher.Flush()
+	if !bw.passthrough {
+		return
+	}
+	if flusher, ok := bw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
 }
 
+// bufferFlush sends a buffered HTML response on to the real ResponseWriter.
+// It's a no-op once a write has already failed or the response was streamed
+// through directly (bufferable returned false), since in both cases the real
+// ResponseWriter either can't be written to or has already received everything.
 func (bw *bufferedResponseWriter) bufferFlush() (written int64, err error) {
-	bw.ResponseWriter.WriteHeader(bw.status)
-	return io.Copy(bw.ResponseWriter, &bw.buf)
+	if bw.writeErr != nil || bw.passthrough {
+		return 0, nil
+	}
+	bw.sendHeader()
+	written, err = io.Copy(bw.ResponseWriter, &bw.buf)
+	if err != nil {
+		bw.writeErr = err
+	}
+	return written, err
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBufferedResponseWriterMultiChunkHTML(t *testing.T) {
+	rec := httptest.NewRecorder()
+	bw := newBufferedResponseWriter(rec)
+	bw.Header().Set("Content-Type", HTMLContentType)
+
+	chunks := []string{"<html><body>", "hello ", "world</body></html>"}
+	for _, chunk := range chunks {
+		if _, err := bw.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	if got, want := bw.buf.String(), strings.Join(chunks, ""); got != want {
+		t.Fatalf("buffered content = %q, want %q", got, want)
+	}
+
+	if _, err := bw.bufferFlush(); err != nil {
+		t.Fatalf("bufferFlush: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got, want := rec.Body.String(), strings.Join(chunks, ""); got != want {
+		t.Fatalf("flushed body = %q, want %q", got, want)
+	}
+}
+
+func TestBufferedResponseWriterNotModified(t *testing.T) {
+	rec := httptest.NewRecorder()
+	bw := newBufferedResponseWriter(rec)
+	bw.WriteHeader(http.StatusNotModified)
+
+	if !bw.passthrough {
+		t.Fatalf("expected a 304 response to switch to passthrough mode")
+	}
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+
+	if _, err := bw.bufferFlush(); err != nil {
+		t.Fatalf("bufferFlush: %v", err)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("body = %q, want empty", rec.Body.String())
+	}
+}
+
+func TestBufferedResponseWriterRangeResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	bw := newBufferedResponseWriter(rec)
+	bw.Header().Set("Content-Type", HTMLContentType)
+	bw.Header().Set("Content-Range", "bytes 0-4/10")
+	bw.WriteHeader(http.StatusPartialContent)
+
+	body := []byte("hello")
+	if _, err := bw.Write(body); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if !bw.passthrough {
+		t.Fatalf("expected a 206 response to switch to passthrough mode")
+	}
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusPartialContent)
+	}
+	if got := rec.Body.String(); got != string(body) {
+		t.Fatalf("body = %q, want %q", got, body)
+	}
+}
+
+func TestBufferedResponseWriterLargeBinaryPassthrough(t *testing.T) {
+	rec := httptest.NewRecorder()
+	bw := newBufferedResponseWriter(rec)
+	bw.Header().Set("Content-Type", "application/octet-stream")
+
+	payload := fakePayload(1 << 20) // 1 MiB, larger than any reasonable buffer
+	if _, err := bw.Write(payload); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if !bw.passthrough {
+		t.Fatalf("expected a non-HTML response to switch to passthrough mode")
+	}
+	if bw.buf.Len() != 0 {
+		t.Fatalf("buf.Len() = %d, want 0 once passthrough has started", bw.buf.Len())
+	}
+	if got := rec.Body.Len(); got != len(payload) {
+		t.Fatalf("flushed %d bytes, want %d", got, len(payload))
+	}
+}
+
+func fakePayload(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+// failingWriter fails every Write after the headers, simulating a client
+// that disconnected mid-response.
+type failingWriter struct {
+	http.ResponseWriter
+}
+
+func (w *failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write: broken pipe")
+}
+
+func TestBufferedResponseWriterStopsAfterWriteError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	bw := newBufferedResponseWriter(&failingWriter{rec})
+	bw.Header().Set("Content-Type", "application/octet-stream")
+
+	if _, err := bw.Write([]byte("chunk one")); err == nil {
+		t.Fatal("expected the first write to fail")
+	}
+
+	n, err := bw.Write([]byte("chunk two"))
+	if err == nil {
+		t.Fatal("expected Write to keep failing without retrying the underlying writer")
+	}
+	if n != 0 {
+		t.Fatalf("n = %d, want 0", n)
+	}
+
+	if _, err := bw.bufferFlush(); err != nil {
+		t.Fatalf("bufferFlush should swallow a sticky write error, got %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Minimal FastCGI Responder client, enough to round-trip a single request
+// per TCP/unix connection. See the FastCGI spec at
+// https://fast-cgi.github.io/spec for the record layout.
+const (
+	fcgiVersion1 = 1
+
+	fcgiBeginRequest = 1
+	fcgiEndRequest   = 3
+	fcgiParams       = 4
+	fcgiStdin        = 5
+	fcgiStdout       = 6
+	fcgiStderr       = 7
+
+	fcgiResponder = 1
+
+	fcgiKeepConn = 1
+
+	fcgiMaxContentLen = 65535
+)
+
+type fcgiHeader struct {
+	Version       uint8
+	Type          uint8
+	RequestID     uint16
+	ContentLength uint16
+	PaddingLength uint8
+	Reserved      uint8
+}
+
+// writeFcgiRecord writes content as one or more records of recType, each
+// capped at fcgiMaxContentLen bytes. An empty (or nil) content writes a
+// single zero-length record, which for PARAMS/STDIN is how the spec marks
+// the end of the stream.
+func writeFcgiRecord(w io.Writer, recType uint8, reqID uint16, content []byte) error {
+	for {
+		chunk := content
+		if len(chunk) > fcgiMaxContentLen {
+			chunk = chunk[:fcgiMaxContentLen]
+		}
+		hdr := fcgiHeader{
+			Version:       fcgiVersion1,
+			Type:          recType,
+			RequestID:     reqID,
+			ContentLength: uint16(len(chunk)),
+		}
+		if err := binary.Write(w, binary.BigEndian, hdr); err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		content = content[len(chunk):]
+		if len(content) == 0 {
+			return nil
+		}
+	}
+}
+
+// writeFcgiNameValue appends a FastCGI name-value pair using the spec's
+// variable-length size encoding (1 byte if <128, 4 bytes otherwise).
+func writeFcgiNameValue(buf *bytes.Buffer, name, value string) {
+	writeFcgiLen(buf, len(name))
+	writeFcgiLen(buf, len(value))
+	buf.WriteString(name)
+	buf.WriteString(value)
+}
+
+func writeFcgiLen(buf *bytes.Buffer, n int) {
+	if n < 128 {
+		buf.WriteByte(byte(n))
+		return
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(n)|1<<31)
+	buf.Write(b[:])
+}
+
+func (sh *scriptHandler) serveFastCGI(w http.ResponseWriter, r *http.Request, relPath, addr string) {
+	network := "tcp"
+	if filepath.IsAbs(addr) {
+		network = "unix"
+	}
+
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(r.Context(), network, addr)
+	if err != nil {
+		serverError(w, fmt.Errorf("fastcgi dial %q: %w", addr, err))
+		return
+	}
+	defer conn.Close()
+
+	// Bail out if the client disconnects or the server shuts down while
+	// we're talking to the upstream; closing conn unblocks the read/write
+	// calls below with an error.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-r.Context().Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	const reqID = 1
+
+	begin := make([]byte, 8)
+	binary.BigEndian.PutUint16(begin[0:2], fcgiResponder)
+	// flags byte (begin[2]) left at 0: don't set fcgiKeepConn, so the
+	// upstream closes the connection once this request is done.
+	if err := writeFcgiRecord(conn, fcgiBeginRequest, reqID, begin); err != nil {
+		serverError(w, fmt.Errorf("fastcgi begin request: %w", err))
+		return
+	}
+
+	scriptPath := filepath.Join(sh.rootDir, filepath.FromSlash(relPath))
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			serverError(w, fmt.Errorf("fastcgi read body: %w", err))
+			return
+		}
+	}
+
+	var params bytes.Buffer
+	writeFcgiNameValue(&params, "REQUEST_METHOD", r.Method)
+	writeFcgiNameValue(&params, "SCRIPT_FILENAME", scriptPath)
+	writeFcgiNameValue(&params, "SCRIPT_NAME", "/"+relPath)
+	writeFcgiNameValue(&params, "QUERY_STRING", r.URL.RawQuery)
+	writeFcgiNameValue(&params, "REQUEST_URI", r.URL.RequestURI())
+	writeFcgiNameValue(&params, "SERVER_PROTOCOL", r.Proto)
+	writeFcgiNameValue(&params, "GATEWAY_INTERFACE", "CGI/1.1")
+	writeFcgiNameValue(&params, "SERVER_SOFTWARE", "serve")
+	writeFcgiNameValue(&params, "CONTENT_TYPE", r.Header.Get("Content-Type"))
+	// r.ContentLength is -1 when unknown (e.g. chunked transfer encoding);
+	// the body is already buffered above, so report its actual length
+	// rather than passing that -1 through to CONTENT_LENGTH.
+	writeFcgiNameValue(&params, "CONTENT_LENGTH", strconv.Itoa(len(body)))
+	if host, port, splitErr := net.SplitHostPort(r.Host); splitErr == nil {
+		writeFcgiNameValue(&params, "SERVER_NAME", host)
+		writeFcgiNameValue(&params, "SERVER_PORT", port)
+	} else {
+		writeFcgiNameValue(&params, "SERVER_NAME", r.Host)
+	}
+	for name, values := range r.Header {
+		writeFcgiNameValue(&params, "HTTP_"+headerToCGIName(name), values[0])
+	}
+	if err := writeFcgiRecord(conn, fcgiParams, reqID, params.Bytes()); err != nil {
+		serverError(w, fmt.Errorf("fastcgi params: %w", err))
+		return
+	}
+	if err := writeFcgiRecord(conn, fcgiParams, reqID, nil); err != nil {
+		serverError(w, fmt.Errorf("fastcgi end params: %w", err))
+		return
+	}
+
+	if len(body) > 0 {
+		if err := writeFcgiRecord(conn, fcgiStdin, reqID, body); err != nil {
+			serverError(w, fmt.Errorf("fastcgi stdin: %w", err))
+			return
+		}
+	}
+	if err := writeFcgiRecord(conn, fcgiStdin, reqID, nil); err != nil {
+		serverError(w, fmt.Errorf("fastcgi end stdin: %w", err))
+		return
+	}
+
+	var stdout, stderr bytes.Buffer
+	br := bufio.NewReader(conn)
+	for {
+		var hdr fcgiHeader
+		if err := binary.Read(br, binary.BigEndian, &hdr); err != nil {
+			serverError(w, fmt.Errorf("fastcgi read header: %w", err))
+			return
+		}
+		content := make([]byte, hdr.ContentLength)
+		if _, err := io.ReadFull(br, content); err != nil {
+			serverError(w, fmt.Errorf("fastcgi read content: %w", err))
+			return
+		}
+		if hdr.PaddingLength > 0 {
+			if _, err := io.CopyN(io.Discard, br, int64(hdr.PaddingLength)); err != nil {
+				serverError(w, fmt.Errorf("fastcgi read padding: %w", err))
+				return
+			}
+		}
+		switch hdr.Type {
+		case fcgiStdout:
+			stdout.Write(content)
+		case fcgiStderr:
+			stderr.Write(content)
+		case fcgiEndRequest:
+			writeFastCGIResponse(w, stdout.Bytes())
+			return
+		}
+	}
+}
+
+// headerToCGIName converts an HTTP header name like "X-Forwarded-For" into
+// the CGI env var suffix "X_FORWARDED_FOR".
+func headerToCGIName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		switch {
+		case c == '-':
+			b[i] = '_'
+		case 'a' <= c && c <= 'z':
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	return string(b)
+}
+
+// writeFastCGIResponse parses the CGI-style header block a FastCGI
+// Responder emits on stdout and writes it through w.
+func writeFastCGIResponse(w http.ResponseWriter, out []byte) {
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(out)))
+	mimeHeader, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		serverError(w, fmt.Errorf("fastcgi parse headers: %w", err))
+		return
+	}
+
+	status := http.StatusOK
+	if s := mimeHeader.Get("Status"); s != "" {
+		if code, convErr := strconv.Atoi(s[:3]); convErr == nil {
+			status = code
+		}
+		mimeHeader.Del("Status")
+	}
+
+	for name, values := range mimeHeader {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+
+	body, _ := io.ReadAll(tp.R)
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeFastCGIResponder accepts a single connection on addr, decodes the
+// PARAMS and STDIN records of one FastCGI request, hands the parsed params
+// and body to check, and replies with a minimal CGI-style stdout record
+// carrying body as the response.
+func fakeFastCGIResponder(t *testing.T, ln net.Listener, body string, check func(params map[string]string, stdin []byte)) {
+	t.Helper()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Errorf("fake fastcgi: accept: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+
+	// readRecord must not call t.Fatalf/t.FailNow: it runs on a goroutine
+	// other than the test's own, where FailNow is not safe to call.
+	readRecord := func() (fcgiHeader, []byte, error) {
+		var hdr fcgiHeader
+		if err := binary.Read(br, binary.BigEndian, &hdr); err != nil {
+			return hdr, nil, fmt.Errorf("read header: %w", err)
+		}
+		content := make([]byte, hdr.ContentLength)
+		if _, err := io.ReadFull(br, content); err != nil {
+			return hdr, nil, fmt.Errorf("read content: %w", err)
+		}
+		if hdr.PaddingLength > 0 {
+			if _, err := io.CopyN(io.Discard, br, int64(hdr.PaddingLength)); err != nil {
+				return hdr, nil, fmt.Errorf("read padding: %w", err)
+			}
+		}
+		return hdr, content, nil
+	}
+
+	// fcgiBeginRequest
+	if _, _, err := readRecord(); err != nil {
+		t.Errorf("fake fastcgi: begin request: %v", err)
+		return
+	}
+
+	params := make(map[string]string)
+	for {
+		hdr, content, err := readRecord()
+		if err != nil {
+			t.Errorf("fake fastcgi: params: %v", err)
+			return
+		}
+		if hdr.Type != fcgiParams {
+			t.Errorf("fake fastcgi: expected PARAMS record, got type %d", hdr.Type)
+			return
+		}
+		if len(content) == 0 {
+			break
+		}
+		buf := bytes.NewBuffer(content)
+		for buf.Len() > 0 {
+			nameLen, err := readFcgiLen(buf)
+			if err != nil {
+				t.Errorf("fake fastcgi: %v", err)
+				return
+			}
+			valueLen, err := readFcgiLen(buf)
+			if err != nil {
+				t.Errorf("fake fastcgi: %v", err)
+				return
+			}
+			name := string(buf.Next(nameLen))
+			value := string(buf.Next(valueLen))
+			params[name] = value
+		}
+	}
+
+	var stdin bytes.Buffer
+	for {
+		hdr, content, err := readRecord()
+		if err != nil {
+			t.Errorf("fake fastcgi: stdin: %v", err)
+			return
+		}
+		if hdr.Type != fcgiStdin {
+			t.Errorf("fake fastcgi: expected STDIN record, got type %d", hdr.Type)
+			return
+		}
+		if len(content) == 0 {
+			break
+		}
+		stdin.Write(content)
+	}
+
+	check(params, stdin.Bytes())
+
+	out := "Content-Type: text/plain\r\n\r\n" + body
+	if err := writeFcgiRecord(conn, fcgiStdout, 1, []byte(out)); err != nil {
+		t.Errorf("fake fastcgi: write stdout: %v", err)
+		return
+	}
+	if err := writeFcgiRecord(conn, fcgiEndRequest, 1, make([]byte, 8)); err != nil {
+		t.Errorf("fake fastcgi: write end request: %v", err)
+		return
+	}
+}
+
+// readFcgiLen mirrors the size decoding writeFcgiLen encodes.
+func readFcgiLen(buf *bytes.Buffer) (int, error) {
+	b, err := buf.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("read length: %w", err)
+	}
+	if b&0x80 == 0 {
+		return int(b), nil
+	}
+	rest := buf.Next(3)
+	n := binary.BigEndian.Uint32(append([]byte{b & 0x7f}, rest...))
+	return int(n), nil
+}
+
+func TestServeFastCGIRoundTrip(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	const reqBody = "name=gopher"
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fakeFastCGIResponder(t, ln, "hello gopher", func(params map[string]string, stdin []byte) {
+			if got, want := params["SCRIPT_FILENAME"], "/srv/root/form.php"; got != want {
+				t.Errorf("SCRIPT_FILENAME = %q, want %q", got, want)
+			}
+			if got, want := params["CONTENT_LENGTH"], strconv.Itoa(len(reqBody)); got != want {
+				t.Errorf("CONTENT_LENGTH = %q, want %q", got, want)
+			}
+			if got := string(stdin); got != reqBody {
+				t.Errorf("stdin = %q, want %q", got, reqBody)
+			}
+		})
+	}()
+
+	sh := &scriptHandler{rootDir: "/srv/root", fcgi: map[string]string{".php": ln.Addr().String()}}
+
+	req := httptest.NewRequest(http.MethodPost, "/form.php", strings.NewReader(reqBody))
+	req.ContentLength = -1 // simulate a chunked request with no known length
+	rec := httptest.NewRecorder()
+
+	sh.serveFastCGI(rec, req, "form.php", ln.Addr().String())
+
+	<-done
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got, want := rec.Body.String(), "hello gopher"; got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitignoreMatcher is a simplified, best-effort reader of a single top-level
+// .gitignore file: blank lines and "#" comments are skipped, a trailing "/"
+// marks a directory-only pattern, and every other pattern is matched with
+// path.Match against both the entry's base name and its path relative to
+// root. It does not implement negation ("!") or "**" globs.
+type gitignoreMatcher struct {
+	dirPatterns  []string
+	filePatterns []string
+}
+
+func loadGitignore(root string) (*gitignoreMatcher, error) {
+	f, err := os.Open(filepath.Join(root, ".gitignore"))
+	if os.IsNotExist(err) {
+		return &gitignoreMatcher{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &gitignoreMatcher{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasSuffix(line, "/") {
+			m.dirPatterns = append(m.dirPatterns, strings.TrimSuffix(strings.TrimPrefix(line, "/"), "/"))
+		} else {
+			m.filePatterns = append(m.filePatterns, strings.TrimPrefix(line, "/"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Match reports whether relPath (slash-separated, relative to root) should be
+// ignored. isDir selects whether dirPatterns are also considered.
+func (m *gitignoreMatcher) Match(relPath string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	base := path.Base(relPath)
+
+	patterns := m.filePatterns
+	if isDir {
+		patterns = append(patterns, m.dirPatterns...)
+	}
+	for _, pat := range patterns {
+		if ok, _ := path.Match(pat, base); ok {
+			return true
+		}
+		if ok, _ := path.Match(pat, relPath); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"flag"
 	"fmt"
@@ -10,20 +12,17 @@ import (
 	"os"
 	"os/signal"
 	"runtime/debug"
-	"slices"
 	"strings"
 	"syscall"
 	"text/template"
 	"time"
-
-	"github.com/fsnotify/fsnotify"
 )
 
 const DefaultPort = "8080"
 const HTMLContentType = "text/html"
 
 const InjectionTmplString = `<script>
-    const sse = new EventSource('http://localhost:{{.Port}}/sse');
+    const sse = new EventSource('{{.Scheme}}://localhost:{{.Port}}/sse');
 	sse.onerror = e => console.error('EventSource failed:', e);
     sse.addEventListener('sourcechange', () => {
         sse.close();
@@ -40,12 +39,23 @@ const InjectionTmplString = `<script>
 var InjectionTmpl = template.Must(template.New("sse").Parse(InjectionTmplString))
 
 type InjectionParams struct {
-	Port string
+	Port   string
+	Scheme string
+}
+
+// stringSlice accumulates repeated occurrences of a flag into a slice.
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(v string) error {
+	*s = append(*s, v)
+	return nil
 }
 
 var reloadBroadcaster = newBroadcaster()
-var ignoredDirs = []string{".git", ".idea", "node_modules"}
-var trackedOp = fsnotify.Create | fsnotify.Write | fsnotify.Remove | fsnotify.Rename
 
 func serverError(w http.ResponseWriter, err error) {
 	fmt.Printf("server error: %v\n", err)
@@ -94,12 +104,104 @@ func main() {
 	var port string
 	var expose bool
 	var injectReload bool
+	var useTLS bool
+	var certFile string
+	var keyFile string
+	var caDir string
+	var scheme string
+	var hosts stringSlice
+	var proxies stringSlice
+	var cgiFlags stringSlice
+	var fcgiFlags stringSlice
+	var watchExt string
+	var pollInterval time.Duration
+	var spa bool
+	var fallback string
+	var noListing bool
+	var listingTemplate string
 
 	flag.StringVar(&port, "port", DefaultPort, "HTTP server port")
 	flag.BoolVar(&expose, "expose", false, "expose the server to all interfaces")
 	flag.BoolVar(&injectReload, "reload", false, "inject auto reload into HTML files")
+	flag.BoolVar(&useTLS, "tls", false, "serve over HTTPS using an on-the-fly self-signed certificate")
+	flag.StringVar(&certFile, "cert", "", "TLS certificate file (enables HTTPS; use with -key)")
+	flag.StringVar(&keyFile, "key", "", "TLS private key file (enables HTTPS; use with -cert)")
+	flag.StringVar(&caDir, "ca", "", "mkcert CA root directory (e.g. $(mkcert -CAROOT)) to sign the generated certificate with")
+	flag.StringVar(&scheme, "scheme", "", "scheme used by the injected reload script's EventSource URL (defaults to https when TLS is enabled, http otherwise)")
+	flag.Var(&hosts, "host", "additional host/IP covered by the generated certificate (repeatable)")
+	flag.Var(&proxies, "proxy", "mount a reverse proxy, format PREFIX=URL, e.g. /api/=http://localhost:3000 (repeatable)")
+	flag.Var(&cgiFlags, "cgi", "dispatch files with EXT to a CGI interpreter, format EXT:INTERPRETER, e.g. .cgi:/usr/bin/perl (repeatable)")
+	flag.Var(&fcgiFlags, "fcgi", "dispatch files with EXT to a FastCGI upstream, format EXT:ADDR, e.g. .php:127.0.0.1:9000 (repeatable)")
+	flag.StringVar(&watchExt, "watch-ext", "", "comma-separated list of extensions that trigger a reload, e.g. .html,.css,.js (defaults to every file)")
+	flag.DurationVar(&pollInterval, "poll", 0, "poll the filesystem for changes instead of using fsnotify, e.g. -poll=500ms (for network mounts and unreliable overlays)")
+	flag.BoolVar(&spa, "spa", false, "serve index.html with 200 for unmatched GETs that accept HTML (client-side router fallback)")
+	flag.StringVar(&fallback, "fallback", "", "path (relative to the served directory) to serve as the SPA fallback; implies -spa")
+	flag.BoolVar(&noListing, "no-listing", false, "return 404 instead of an auto-generated directory listing")
+	flag.StringVar(&listingTemplate, "listing-template", "", "text/template file to render directory listings with, receiving {Path, Entries []{Name, Size, ModTime, IsDir}}")
 	flag.Parse()
 
+	useTLS = useTLS || certFile != "" || keyFile != "" || caDir != ""
+
+	if (certFile != "") != (keyFile != "") {
+		fmt.Println("-cert and -key must be given together.")
+		os.Exit(1)
+	}
+
+	if noListing && listingTemplate != "" {
+		fmt.Println("-no-listing and -listing-template are mutually exclusive.")
+		os.Exit(1)
+	}
+
+	spa = spa || fallback != ""
+	if spa && fallback == "" {
+		fallback = "index.html"
+	}
+
+	var listingTmpl *template.Template
+	if listingTemplate != "" {
+		var err error
+		listingTmpl, err = parseListingTemplate(listingTemplate)
+		if err != nil {
+			fmt.Printf("failed to parse -listing-template: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	proxyRules := make([]proxyRule, len(proxies))
+	for i, raw := range proxies {
+		rule, err := parseProxyRule(raw)
+		if err != nil {
+			fmt.Printf("invalid -proxy value: %v\n", err)
+			os.Exit(1)
+		}
+		proxyRules[i] = rule
+	}
+
+	cgiRules := make([]cgiRule, len(cgiFlags))
+	for i, raw := range cgiFlags {
+		ext, interpreter, err := parseExtRule(raw)
+		if err != nil {
+			fmt.Printf("invalid -cgi value: %v\n", err)
+			os.Exit(1)
+		}
+		cgiRules[i] = cgiRule{ext: ext, interpreter: interpreter}
+	}
+
+	fcgiRules := make([]fcgiRule, len(fcgiFlags))
+	for i, raw := range fcgiFlags {
+		ext, addr, err := parseExtRule(raw)
+		if err != nil {
+			fmt.Printf("invalid -fcgi value: %v\n", err)
+			os.Exit(1)
+		}
+		fcgiRules[i] = fcgiRule{ext: ext, addr: addr}
+	}
+
+	var watchExts []string
+	if watchExt != "" {
+		watchExts = strings.Split(watchExt, ",")
+	}
+
 	for _, c := range port {
 		if '0' <= c && c <= '9' {
 			continue
@@ -108,8 +210,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	if scheme == "" {
+		if useTLS {
+			scheme = "https"
+		} else {
+			scheme = "http"
+		}
+	}
+
 	var injectionSB strings.Builder
-	if err := InjectionTmpl.Execute(&injectionSB, InjectionParams{Port: port}); err != nil {
+	if err := InjectionTmpl.Execute(&injectionSB, InjectionParams{Port: port, Scheme: scheme}); err != nil {
 		fmt.Printf("failed to execute injection template: %v\n", err)
 		os.Exit(1)
 	}
@@ -144,14 +254,41 @@ func main() {
 
 	mux := http.NewServeMux()
 	fileServer := http.FileServerFS(rootFS)
+	staticHandler := newStaticHandler(rootFS, fileServer, staticOptions{
+		fallback:    fallback,
+		noListing:   noListing,
+		listingTmpl: listingTmpl,
+	})
+	var rootHandler http.Handler = staticHandler
+	if len(cgiRules) > 0 || len(fcgiRules) > 0 {
+		rootHandler = newScriptHandler(dir, rootFS, cgiRules, fcgiRules, staticHandler)
+	}
 	var handler http.Handler
 
+	for _, rule := range proxyRules {
+		h := newProxyHandler(rule.prefix, rule.target)
+		for _, method := range proxyMethods {
+			mux.Handle(method+" "+rule.prefix, h)
+		}
+	}
+
+	rootMethods := []string{http.MethodGet}
+	if len(cgiRules) > 0 || len(fcgiRules) > 0 {
+		// Scripts may handle any method (form submissions, REST calls, ...),
+		// unlike the static handler, which only ever serves GET.
+		rootMethods = proxyMethods
+	}
+
 	if injectReload {
-		mux.Handle("GET /", withInjectReload(fileServer, injection))
+		for _, method := range rootMethods {
+			mux.Handle(method+" /", withInjectReload(rootHandler, injection))
+		}
 		mux.HandleFunc("GET /sse", liveReloadHandler)
 		handler = withRecoverPanic(withRequestCancel(withNoCache(mux), ctx))
 	} else {
-		mux.Handle("GET /", fileServer)
+		for _, method := range rootMethods {
+			mux.Handle(method+" /", rootHandler)
+		}
 		handler = withRecoverPanic(withRequestCancel(mux, ctx))
 	}
 
@@ -172,13 +309,35 @@ func main() {
 		MaxHeaderBytes:    8_192,
 	}
 
+	if useTLS && certFile == "" {
+		certHosts := append([]string{"localhost", "127.0.0.1", "::1"}, hosts...)
+
+		var ca *x509.Certificate
+		var caKey any
+		if caDir != "" {
+			ca, caKey, err = loadMkcertCA(caDir)
+			if err != nil {
+				fmt.Printf("failed to load CA from %q: %v\n", caDir, err)
+				os.Exit(1)
+			}
+		}
+
+		cp := newCertProvider(certHosts, ca, caKey)
+		srv.TLSConfig = &tls.Config{GetCertificate: cp.GetCertificate}
+	}
+
 	stopC := make(chan os.Signal, 1)
 	signal.Notify(stopC, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
 
 	go func() {
 		fmt.Printf("starting server on %q...\n", srv.Addr)
 
-		err := srv.ListenAndServe()
+		var err error
+		if useTLS {
+			err = srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			fmt.Printf("server failed: %v\n", err)
 			os.Exit(1)
@@ -189,8 +348,14 @@ func main() {
 		if !injectReload {
 			return
 		}
-		debounce := newDebouncer(100 * time.Millisecond)
-		watcher, err := fsnotify.NewWatcher()
+
+		var watcher fsWatcher
+		var err error
+		if pollInterval > 0 {
+			watcher, err = newPollWatcher(dir, pollInterval, watchExts)
+		} else {
+			watcher, err = newRecursiveWatcher(dir, watchExts)
+		}
 		if err != nil {
 			fmt.Printf("failed to create a watcher: %v\n", err)
 			os.Exit(1)
@@ -201,43 +366,8 @@ func main() {
 				os.Exit(1)
 			}
 		}()
-		err = fs.WalkDir(rootFS, ".", func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if !d.IsDir() {
-				return nil
-			}
-			if d.IsDir() && slices.Contains(ignoredDirs, d.Name()) {
-				return fs.SkipDir
-			}
-			return watcher.Add(path)
-		})
-		if err != nil {
-			fmt.Printf("error occured while trying to register the fs tree: %v\n", err)
-			os.Exit(1)
-		}
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case ev, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if ev.Op&trackedOp == 0 {
-					continue
-				}
-				debounce.Call("reload", func() {
-					reloadBroadcaster.notify()
-				})
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				fmt.Printf("watcher error: %v\n", err)
-			}
-		}
+
+		watcher.Run(ctx, reloadBroadcaster.notify)
 	}()
 
 	<-stopC
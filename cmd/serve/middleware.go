@@ -9,8 +9,6 @@ import (
 	"strings"
 )
 
-const HTMLContentType = "text/html"
-
 func withRecoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -59,7 +57,7 @@ func withInjectReload(next http.Handler, injection string) http.Handler {
 
 		next.ServeHTTP(bw, r)
 
-		if r.Header.Get("Range") != "" {
+		if bw.passthrough {
 			return
 		}
 		if !strings.Contains(bw.Header().Get("Content-Type"), HTMLContentType) {
@@ -77,8 +75,7 @@ func withInjectReload(next http.Handler, injection string) http.Handler {
 			bw.Header().Set("Content-Length", strconv.Itoa(n+len(injection)))
 		}
 
-		if _, err := fmt.Fprint(bw, htmlStr); err != nil {
-			serverError(w, err)
-		}
+		bw.buf.Reset()
+		bw.buf.WriteString(htmlStr)
 	})
 }
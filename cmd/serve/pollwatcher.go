@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// pollWatcher is a fallback for filesystems where fsnotify is unreliable
+// (network mounts, some container overlays): it re-walks the tree on an
+// interval and compares file mtimes against the previous pass instead of
+// relying on kernel notifications.
+type pollWatcher struct {
+	root      string
+	interval  time.Duration
+	gitignore *gitignoreMatcher
+	exts      []string
+	mtimes    map[string]time.Time
+}
+
+func newPollWatcher(root string, interval time.Duration, exts []string) (*pollWatcher, error) {
+	gi, err := loadGitignore(root)
+	if err != nil {
+		return nil, err
+	}
+
+	pw := &pollWatcher{
+		root:      root,
+		interval:  interval,
+		gitignore: gi,
+		exts:      exts,
+	}
+	pw.mtimes, err = pw.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (pw *pollWatcher) shouldSkipDir(path string) bool {
+	if path == pw.root {
+		return false
+	}
+	if slices.Contains(ignoredDirs, filepath.Base(path)) {
+		return true
+	}
+	rel, err := filepath.Rel(pw.root, path)
+	if err != nil {
+		return false
+	}
+	return pw.gitignore.Match(filepath.ToSlash(rel), true)
+}
+
+func (pw *pollWatcher) matchesExt(path string) bool {
+	if len(pw.exts) == 0 {
+		return true
+	}
+	return slices.Contains(pw.exts, filepath.Ext(path))
+}
+
+func (pw *pollWatcher) snapshot() (map[string]time.Time, error) {
+	mtimes := make(map[string]time.Time)
+	err := filepath.WalkDir(pw.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if pw.shouldSkipDir(path) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !pw.matchesExt(path) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mtimes[path] = fi.ModTime()
+		return nil
+	})
+	return mtimes, err
+}
+
+func (pw *pollWatcher) changed(next map[string]time.Time) bool {
+	if len(next) != len(pw.mtimes) {
+		return true
+	}
+	for path, mtime := range next {
+		prev, ok := pw.mtimes[path]
+		if !ok || !mtime.Equal(prev) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pw *pollWatcher) Run(ctx context.Context, onChange func()) {
+	ticker := time.NewTicker(pw.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			next, err := pw.snapshot()
+			if err != nil {
+				fmt.Printf("poll watcher error: %v\n", err)
+				continue
+			}
+			if pw.changed(next) {
+				onChange()
+			}
+			pw.mtimes = next
+		}
+	}
+}
+
+func (pw *pollWatcher) Close() error {
+	return nil
+}
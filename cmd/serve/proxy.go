@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// proxyMethods are the HTTP methods registered for each -proxy mount point.
+var proxyMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// proxyRule is a parsed `-proxy PREFIX=URL` flag value.
+type proxyRule struct {
+	prefix string
+	target *url.URL
+}
+
+// parseProxyRule parses a `-proxy` flag value of the form
+// "/api/=http://localhost:3000" into a proxyRule. prefix must start and end
+// with "/" so it lines up with how http.ServeMux registers subtree patterns.
+func parseProxyRule(raw string) (proxyRule, error) {
+	prefix, rawURL, ok := strings.Cut(raw, "=")
+	if !ok {
+		return proxyRule{}, fmt.Errorf("expected PREFIX=URL, got %q", raw)
+	}
+	if !strings.HasPrefix(prefix, "/") || !strings.HasSuffix(prefix, "/") {
+		return proxyRule{}, fmt.Errorf("proxy prefix %q must start and end with %q", prefix, "/")
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return proxyRule{}, fmt.Errorf("invalid proxy target %q: %w", rawURL, err)
+	}
+	if target.Scheme == "" || target.Host == "" {
+		return proxyRule{}, fmt.Errorf("proxy target %q must be an absolute URL", rawURL)
+	}
+	return proxyRule{prefix: prefix, target: target}, nil
+}
+
+// newProxyHandler mounts a reverse proxy to target at prefix, stripping the
+// prefix from the forwarded request path. WebSocket upgrade requests are
+// hijacked and shuttled directly instead of going through
+// httputil.ReverseProxy, which doesn't support them.
+func newProxyHandler(prefix string, target *url.URL) http.Handler {
+	rp := httputil.NewSingleHostReverseProxy(target)
+	director := rp.Director
+	rp.Director = func(r *http.Request) {
+		director(r)
+		if _, ok := r.Header["X-Forwarded-Host"]; !ok {
+			r.Header.Set("X-Forwarded-Host", r.Host)
+		}
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		r.Header.Set("X-Forwarded-Proto", scheme)
+	}
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		serverError(w, fmt.Errorf("proxy %s: %w", target, err))
+	}
+
+	stripped := http.StripPrefix(strings.TrimSuffix(prefix, "/"), rp)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isWebSocketUpgrade(r) {
+			proxyWebSocket(w, r, prefix, target)
+			return
+		}
+		stripped.ServeHTTP(w, r)
+	})
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		slicesContainsFold(r.Header.Values("Connection"), "upgrade")
+}
+
+func slicesContainsFold(values []string, want string) bool {
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxyWebSocket dials target, forwards the (prefix-stripped) upgrade
+// request as-is, and then copies bytes in both directions until either side
+// closes the connection.
+func proxyWebSocket(w http.ResponseWriter, r *http.Request, prefix string, target *url.URL) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		serverError(w, fmt.Errorf("websocket proxy: response writer does not support hijacking"))
+		return
+	}
+
+	addr := target.Host
+	if !strings.Contains(addr, ":") {
+		if target.Scheme == "https" || target.Scheme == "wss" {
+			addr += ":443"
+		} else {
+			addr += ":80"
+		}
+	}
+
+	var upstream net.Conn
+	var err error
+	if target.Scheme == "https" || target.Scheme == "wss" {
+		upstream, err = tls.Dial("tcp", addr, &tls.Config{ServerName: target.Hostname()})
+	} else {
+		upstream, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		serverError(w, fmt.Errorf("websocket proxy: dial %q: %w", addr, err))
+		return
+	}
+	defer upstream.Close()
+
+	client, _, err := hj.Hijack()
+	if err != nil {
+		serverError(w, fmt.Errorf("websocket proxy: hijack: %w", err))
+		return
+	}
+	defer client.Close()
+
+	r.URL.Path = strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(prefix, "/"))
+	if r.URL.Path == "" {
+		r.URL.Path = "/"
+	}
+
+	if clientIP, _, splitErr := net.SplitHostPort(r.RemoteAddr); splitErr == nil {
+		if prior, ok := r.Header["X-Forwarded-For"]; ok {
+			clientIP = strings.Join(prior, ", ") + ", " + clientIP
+		}
+		r.Header.Set("X-Forwarded-For", clientIP)
+	}
+	if _, ok := r.Header["X-Forwarded-Host"]; !ok {
+		r.Header.Set("X-Forwarded-Host", r.Host)
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	r.Header.Set("X-Forwarded-Proto", scheme)
+
+	r.Host = target.Host
+	r.RequestURI = ""
+
+	if err := r.Write(upstream); err != nil {
+		return
+	}
+
+	// A hijacked connection is detached from the http.Server, so closing
+	// r.Context() (e.g. on shutdown) has no effect on the relay below
+	// unless we watch it ourselves and close both ends.
+	relayDone := make(chan struct{})
+	defer close(relayDone)
+	go func() {
+		select {
+		case <-r.Context().Done():
+			upstream.Close()
+			client.Close()
+		case <-relayDone:
+		}
+	}()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/cgi"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// cgiRule is a parsed `-cgi EXT:INTERPRETER` flag value, e.g. ".cgi:/usr/bin/perl".
+type cgiRule struct {
+	ext         string
+	interpreter string
+}
+
+// fcgiRule is a parsed `-fcgi EXT:ADDR` flag value, e.g. ".php:127.0.0.1:9000".
+type fcgiRule struct {
+	ext  string
+	addr string
+}
+
+// parseExtRule splits a "EXT:VALUE" flag value and validates the extension.
+func parseExtRule(raw string) (ext, value string, err error) {
+	ext, value, ok := strings.Cut(raw, ":")
+	if !ok {
+		return "", "", fmt.Errorf("expected EXT:VALUE, got %q", raw)
+	}
+	if !strings.HasPrefix(ext, ".") {
+		return "", "", fmt.Errorf("extension %q must start with %q", ext, ".")
+	}
+	if value == "" {
+		return "", "", fmt.Errorf("missing value after %q", ext+":")
+	}
+	return ext, value, nil
+}
+
+// scriptHandler dispatches requests to a CGI interpreter or a FastCGI
+// upstream based on the extension of the resolved file, falling back to
+// next (the static file server) for everything else.
+type scriptHandler struct {
+	rootDir string
+	rootFS  fs.FS
+	cgi     map[string]string
+	fcgi    map[string]string
+	next    http.Handler
+}
+
+func newScriptHandler(rootDir string, rootFS fs.FS, cgiRules []cgiRule, fcgiRules []fcgiRule, next http.Handler) http.Handler {
+	sh := &scriptHandler{
+		rootDir: rootDir,
+		rootFS:  rootFS,
+		cgi:     make(map[string]string, len(cgiRules)),
+		fcgi:    make(map[string]string, len(fcgiRules)),
+		next:    next,
+	}
+	for _, r := range cgiRules {
+		sh.cgi[r.ext] = r.interpreter
+	}
+	for _, r := range fcgiRules {
+		sh.fcgi[r.ext] = r.addr
+	}
+	return sh
+}
+
+func (sh *scriptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	relPath := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+
+	fi, err := fs.Stat(sh.rootFS, relPath)
+	if err != nil || fi.IsDir() {
+		sh.next.ServeHTTP(w, r)
+		return
+	}
+
+	ext := filepath.Ext(relPath)
+
+	if interpreter, ok := sh.cgi[ext]; ok {
+		sh.serveCGI(w, r, relPath, interpreter)
+		return
+	}
+	if addr, ok := sh.fcgi[ext]; ok {
+		sh.serveFastCGI(w, r, relPath, addr)
+		return
+	}
+
+	sh.next.ServeHTTP(w, r)
+}
+
+func (sh *scriptHandler) serveCGI(w http.ResponseWriter, r *http.Request, relPath, interpreter string) {
+	scriptPath := filepath.Join(sh.rootDir, filepath.FromSlash(relPath))
+
+	h := &cgi.Handler{
+		Path: interpreter,
+		Dir:  filepath.Dir(scriptPath),
+		Args: []string{scriptPath},
+		Root: "/",
+	}
+	h.ServeHTTP(w, r)
+}
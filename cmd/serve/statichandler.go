@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// staticOptions configures newStaticHandler.
+type staticOptions struct {
+	// fallback, if non-empty, is the rootFS path served with HTTP 200 for any
+	// GET that would otherwise 404 and whose Accept header includes
+	// text/html — the SPA history-fallback pattern.
+	fallback string
+	// noListing suppresses http.FileServerFS's auto-generated directory
+	// index, returning 404 instead.
+	noListing bool
+	// listingTmpl, if set, renders directory listings instead of delegating
+	// to http.FileServerFS.
+	listingTmpl *template.Template
+}
+
+// listingEntry is a single row passed to a -listing-template.
+type listingEntry struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// listingData is the value passed to a -listing-template.
+type listingData struct {
+	Path    string
+	Entries []listingEntry
+}
+
+// parseListingTemplate reads and parses a -listing-template file. The
+// template is invoked with a listingData value.
+func parseListingTemplate(file string) (*template.Template, error) {
+	return template.ParseFiles(file)
+}
+
+// staticHandler wraps http.FileServerFS to add an SPA history fallback and
+// configurable directory-listing behavior. It fs.Stats the resolved path
+// against rootFS first, so it can decide between fallback, listing, or
+// plain delegation before the stdlib handler ever sees the request.
+type staticHandler struct {
+	rootFS fs.FS
+	next   http.Handler // http.FileServerFS(rootFS)
+	opts   staticOptions
+}
+
+func newStaticHandler(rootFS fs.FS, next http.Handler, opts staticOptions) *staticHandler {
+	return &staticHandler{rootFS: rootFS, next: next, opts: opts}
+}
+
+func (sh *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	relPath := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if relPath == "" {
+		relPath = "."
+	}
+
+	fi, err := fs.Stat(sh.rootFS, relPath)
+	if err != nil {
+		if r.Method == http.MethodGet && sh.opts.fallback != "" && strings.Contains(r.Header.Get("Accept"), HTMLContentType) {
+			sh.serveFallback(w, r)
+			return
+		}
+		sh.next.ServeHTTP(w, r)
+		return
+	}
+
+	if fi.IsDir() {
+		if sh.opts.listingTmpl != nil {
+			if !strings.HasSuffix(r.URL.Path, "/") {
+				to := path.Base(r.URL.Path) + "/"
+				if r.URL.RawQuery != "" {
+					to += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, to, http.StatusMovedPermanently)
+				return
+			}
+			sh.serveListing(w, r, relPath)
+			return
+		}
+		if sh.opts.noListing {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	sh.next.ServeHTTP(w, r)
+}
+
+func (sh *staticHandler) serveFallback(w http.ResponseWriter, r *http.Request) {
+	f, err := sh.rootFS.Open(sh.opts.fallback)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		serverError(w, fmt.Errorf("fallback file %q does not support seeking", sh.opts.fallback))
+		return
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", HTMLContentType)
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), rs)
+}
+
+func (sh *staticHandler) serveListing(w http.ResponseWriter, r *http.Request, relPath string) {
+	dirEntries, err := fs.ReadDir(sh.rootFS, relPath)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	entries := make([]listingEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		info, err := de.Info()
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		entries = append(entries, listingEntry{
+			Name:    de.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   de.IsDir(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	dataPath := "/" + relPath
+	if relPath == "." {
+		dataPath = "/"
+	}
+	data := listingData{Path: dataPath, Entries: entries}
+
+	w.Header().Set("Content-Type", HTMLContentType)
+	if err := sh.opts.listingTmpl.Execute(w, data); err != nil {
+		fmt.Printf("failed to render directory listing: %v\n", err)
+	}
+}
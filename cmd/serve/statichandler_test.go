@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+)
+
+func newTestStaticHandler(t *testing.T, root string, opts staticOptions) http.Handler {
+	t.Helper()
+	rootFS := os.DirFS(root)
+	return newStaticHandler(rootFS, http.FileServerFS(rootFS), opts)
+}
+
+func TestStaticHandlerSPAFallback(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "index.html"), []byte("<html><body>app</body></html>"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	h := newTestStaticHandler(t, root, staticOptions{fallback: "index.html"})
+
+	req := httptest.NewRequest(http.MethodGet, "/dashboard/settings", nil)
+	req.Header.Set("Accept", "text/html,*/*")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec.Body.String() != "<html><body>app</body></html>" {
+		t.Fatalf("body = %q, want the fallback file's content", rec.Body.String())
+	}
+}
+
+func TestStaticHandlerSPAFallbackSkippedForNonHTML(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "index.html"), []byte("app"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	h := newTestStaticHandler(t, root, staticOptions{fallback: "index.html"})
+
+	req := httptest.NewRequest(http.MethodGet, "/missing.json", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestStaticHandlerNoListing(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "assets"), 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+
+	h := newTestStaticHandler(t, root, staticOptions{noListing: true})
+
+	req := httptest.NewRequest(http.MethodGet, "/assets/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestStaticHandlerListingTemplate(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "b.txt"), []byte("b"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	tmpl := template.Must(template.New("listing").Parse(`{{.Path}}:{{range .Entries}}{{.Name}},{{end}}`))
+	h := newTestStaticHandler(t, root, staticOptions{listingTmpl: tmpl})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got, want := rec.Body.String(), "/:a.txt,b.txt,"; got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+}
+
+func TestStaticHandlerListingTemplateRedirectsWithoutTrailingSlash(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "assets"), 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+
+	tmpl := template.Must(template.New("listing").Parse(`{{.Path}}`))
+	h := newTestStaticHandler(t, root, staticOptions{listingTmpl: tmpl})
+
+	req := httptest.NewRequest(http.MethodGet, "/assets", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMovedPermanently {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusMovedPermanently)
+	}
+	if got, want := rec.Header().Get("Location"), "/assets/"; got != want {
+		t.Fatalf("Location = %q, want %q", got, want)
+	}
+}
+
+func TestStaticHandlerDelegatesRegularFiles(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "style.css"), []byte("body{}"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	h := newTestStaticHandler(t, root, staticOptions{fallback: "index.html", noListing: true})
+
+	req := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+	req.Header.Set("Accept", "text/css")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec.Body.String() != "body{}" {
+		t.Fatalf("body = %q, want %q", rec.Body.String(), "body{}")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const leafCertLifetime = 7 * 24 * time.Hour
+const leafCertRenewBefore = time.Hour
+
+// certProvider lazily generates an ECDSA P-256 leaf certificate covering
+// localhost, 127.0.0.1, ::1 and any additional hosts, caching it until it's
+// close to expiring. If a local CA is configured, the leaf is signed by it
+// so browsers that trust the CA accept the certificate without warnings;
+// otherwise the leaf signs itself.
+type certProvider struct {
+	hosts  []string
+	ca     *x509.Certificate
+	caKey  any
+	mu     sync.Mutex
+	cached *tls.Certificate
+}
+
+func newCertProvider(hosts []string, ca *x509.Certificate, caKey any) *certProvider {
+	return &certProvider{hosts: hosts, ca: ca, caKey: caKey}
+}
+
+func (cp *certProvider) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.cached != nil {
+		leaf := cp.cached.Leaf
+		if leaf != nil && time.Now().Before(leaf.NotAfter.Add(-leafCertRenewBefore)) {
+			return cp.cached, nil
+		}
+	}
+
+	cert, err := generateLeafCert(cp.hosts, cp.ca, cp.caKey)
+	if err != nil {
+		return nil, err
+	}
+	cp.cached = cert
+	return cert, nil
+}
+
+// generateLeafCert mints a fresh ECDSA P-256 leaf certificate for hosts. If
+// ca and caKey are non-nil the leaf is signed by that CA; otherwise it's
+// self-signed.
+func generateLeafCert(hosts []string, ca *x509.Certificate, caKey any) (*tls.Certificate, error) {
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate leaf key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "serve local development certificate"},
+		NotBefore:    now.Add(-5 * time.Minute),
+		NotAfter:     now.Add(leafCertLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	parent := template
+	signerKey := any(leafKey)
+	if ca != nil && caKey != nil {
+		parent = ca
+		signerKey = caKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &leafKey.PublicKey, signerKey)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse generated certificate: %w", err)
+	}
+
+	chain := [][]byte{der}
+	if ca != nil {
+		chain = append(chain, ca.Raw)
+	}
+
+	return &tls.Certificate{
+		Certificate: chain,
+		PrivateKey:  leafKey,
+		Leaf:        leaf,
+	}, nil
+}
+
+// loadMkcertCA reads rootCA.pem and rootCA-key.pem from dir, the layout
+// produced by `mkcert -CAROOT`, so generated leaves can be signed by a CA
+// the user has already installed in their local trust store.
+func loadMkcertCA(dir string) (*x509.Certificate, any, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, "rootCA.pem"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read rootCA.pem: %w", err)
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, "rootCA-key.pem"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read rootCA-key.pem: %w", err)
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("rootCA.pem: no PEM block found")
+	}
+	ca, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse rootCA.pem: %w", err)
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, nil, fmt.Errorf("rootCA-key.pem: no PEM block found")
+	}
+	caKey, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse rootCA-key.pem: %w", err)
+	}
+
+	return ca, caKey, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var ignoredDirs = []string{".git", ".idea", "node_modules"}
+var trackedOp = fsnotify.Create | fsnotify.Write | fsnotify.Remove | fsnotify.Rename
+
+// fsWatcher is satisfied by both the fsnotify-backed recursiveWatcher and the
+// pollWatcher fallback, so main can pick one without branching downstream.
+type fsWatcher interface {
+	Run(ctx context.Context, onChange func())
+	Close() error
+}
+
+// recursiveWatcher wraps an *fsnotify.Watcher so that directories created
+// after startup get watched automatically and directories that are removed
+// or renamed away stop being watched, neither of which fsnotify does on its
+// own. Changes are coalesced per path through a debouncer before onChange is
+// called, and files can be excluded by extension.
+type recursiveWatcher struct {
+	fsw       *fsnotify.Watcher
+	root      string
+	gitignore *gitignoreMatcher
+	exts      []string
+	watched   map[string]struct{}
+	debounce  *debouncer
+}
+
+func newRecursiveWatcher(root string, exts []string) (*recursiveWatcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	gi, err := loadGitignore(root)
+	if err != nil {
+		fsw.Close()
+		return nil, err
+	}
+
+	rw := &recursiveWatcher{
+		fsw:       fsw,
+		root:      root,
+		gitignore: gi,
+		exts:      exts,
+		watched:   make(map[string]struct{}),
+		debounce:  newDebouncer(100 * time.Millisecond),
+	}
+	if err := rw.addTree(root); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	return rw, nil
+}
+
+func (rw *recursiveWatcher) shouldSkipDir(path string) bool {
+	if path == rw.root {
+		return false
+	}
+	if slices.Contains(ignoredDirs, filepath.Base(path)) {
+		return true
+	}
+	rel, err := filepath.Rel(rw.root, path)
+	if err != nil {
+		return false
+	}
+	return rw.gitignore.Match(filepath.ToSlash(rel), true)
+}
+
+// addTree walks dir and adds it and every non-ignored subdirectory to the
+// underlying watcher, recording each in watched.
+func (rw *recursiveWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if rw.shouldSkipDir(path) {
+			return fs.SkipDir
+		}
+		if err := rw.fsw.Add(path); err != nil {
+			return err
+		}
+		rw.watched[path] = struct{}{}
+		return nil
+	})
+}
+
+// removeTree stops watching path and every previously-watched descendant of
+// it, e.g. after a watched directory is removed or renamed away.
+func (rw *recursiveWatcher) removeTree(path string) {
+	prefix := path + string(filepath.Separator)
+	for watchedPath := range rw.watched {
+		if watchedPath == path || strings.HasPrefix(watchedPath, prefix) {
+			rw.fsw.Remove(watchedPath)
+			delete(rw.watched, watchedPath)
+		}
+	}
+}
+
+// matchesExt reports whether path should trigger onChange, given the
+// configured extension filter; an empty filter matches everything.
+func (rw *recursiveWatcher) matchesExt(path string) bool {
+	if len(rw.exts) == 0 {
+		return true
+	}
+	return slices.Contains(rw.exts, filepath.Ext(path))
+}
+
+func (rw *recursiveWatcher) Run(ctx context.Context, onChange func()) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-rw.fsw.Events:
+			if !ok {
+				return
+			}
+			rw.handleEvent(ev, onChange)
+		case err, ok := <-rw.fsw.Errors:
+			if !ok {
+				return
+			}
+			fmt.Printf("watcher error: %v\n", err)
+		}
+	}
+}
+
+func (rw *recursiveWatcher) handleEvent(ev fsnotify.Event, onChange func()) {
+	if ev.Op&fsnotify.Create != 0 {
+		if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() && !rw.shouldSkipDir(ev.Name) {
+			if err := rw.addTree(ev.Name); err != nil {
+				fmt.Printf("failed to watch %q: %v\n", ev.Name, err)
+			}
+		}
+	}
+	if ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		if _, ok := rw.watched[ev.Name]; ok {
+			rw.removeTree(ev.Name)
+		}
+	}
+
+	if ev.Op&trackedOp == 0 || !rw.matchesExt(ev.Name) {
+		return
+	}
+	rw.debounce.Call(ev.Name, onChange)
+}
+
+func (rw *recursiveWatcher) Close() error {
+	return rw.fsw.Close()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitForChange(t *testing.T, changed chan struct{}) {
+	t.Helper()
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a change notification")
+	}
+}
+
+func TestRecursiveWatcherWatchesNewDirectories(t *testing.T) {
+	root := t.TempDir()
+
+	rw, err := newRecursiveWatcher(root, nil)
+	if err != nil {
+		t.Fatalf("newRecursiveWatcher: %v", err)
+	}
+	defer rw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	go rw.Run(ctx, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	waitForChange(t, changed)
+
+	if _, ok := rw.watched[sub]; !ok {
+		t.Fatalf("expected %q to be watched after it was created", sub)
+	}
+
+	if err := os.WriteFile(filepath.Join(sub, "file.txt"), []byte("hi"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	waitForChange(t, changed)
+}
+
+func TestRecursiveWatcherStopsWatchingRemovedDirectories(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+
+	rw, err := newRecursiveWatcher(root, nil)
+	if err != nil {
+		t.Fatalf("newRecursiveWatcher: %v", err)
+	}
+	defer rw.Close()
+
+	if _, ok := rw.watched[sub]; !ok {
+		t.Fatalf("expected %q to be watched at startup", sub)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	go rw.Run(ctx, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	if err := os.Remove(sub); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	waitForChange(t, changed)
+
+	if _, ok := rw.watched[sub]; ok {
+		t.Fatalf("expected %q to no longer be watched after removal", sub)
+	}
+}
+
+func TestRecursiveWatcherExtensionFilter(t *testing.T) {
+	root := t.TempDir()
+
+	rw, err := newRecursiveWatcher(root, []string{".html"})
+	if err != nil {
+		t.Fatalf("newRecursiveWatcher: %v", err)
+	}
+	defer rw.Close()
+
+	if rw.matchesExt(filepath.Join(root, "index.html")) != true {
+		t.Fatal("expected .html to match the configured extension filter")
+	}
+	if rw.matchesExt(filepath.Join(root, "index.swp")) {
+		t.Fatal("expected .swp not to match the configured extension filter")
+	}
+}
+
+func TestPollWatcherDetectsChanges(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "index.html")
+	if err := os.WriteFile(file, []byte("v1"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	pw, err := newPollWatcher(root, 20*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("newPollWatcher: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	go pw.Run(ctx, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	newTime := time.Now().Add(time.Second)
+	if err := os.Chtimes(file, newTime, newTime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	waitForChange(t, changed)
+}
+
+func TestGitignoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	gitignore := "# comment\n\n*.log\nbuild/\n"
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte(gitignore), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	m, err := loadGitignore(root)
+	if err != nil {
+		t.Fatalf("loadGitignore: %v", err)
+	}
+
+	if !m.Match("debug.log", false) {
+		t.Fatal("expected debug.log to be ignored")
+	}
+	if !m.Match("build", true) {
+		t.Fatal("expected the build directory to be ignored")
+	}
+	if m.Match("index.html", false) {
+		t.Fatal("expected index.html not to be ignored")
+	}
+}